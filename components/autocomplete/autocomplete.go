@@ -2,12 +2,13 @@ package autocomplete
 
 import (
 	"context"
-	"log"
+	"sync"
 	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
@@ -18,6 +19,7 @@ type ctxKey uint
 const (
 	_ ctxKey = iota
 	iterDataCtx
+	matchesCtx
 )
 
 // WhitespaceRune is a special rune that Searcher can return to indicate that it
@@ -35,6 +37,20 @@ type Searcher interface {
 	Search(ctx context.Context, str string) []Data
 }
 
+// AsyncSearcher is an optional interface a Searcher may additionally
+// implement to stream results back as they arrive, instead of blocking until
+// all of them are ready. This suits slow, network-backed sources such as a
+// Discord member search or an HTTP completion endpoint. Results sent on the
+// returned channel are merged with those of every other searcher triggered
+// for the same word and re-ranked as they come in.
+type AsyncSearcher interface {
+	Searcher
+	// SearchAsync searches the given string, sending results on the returned
+	// channel as they become available. The channel must be closed once no
+	// more results will be sent, and must respect ctx's cancellation.
+	SearchAsync(ctx context.Context, str string) <-chan Data
+}
+
 // IterData contains iterator data that's given to Searcher.Search's context.
 // Use IterDataFromContext to get it.
 type IterData struct {
@@ -52,6 +68,16 @@ func IterDataFromContext(ctx context.Context) *IterData {
 	return data
 }
 
+// MatchesFromContext returns the rune indices into the current ranked
+// candidate's Matchable.MatchText that matched the search query, for use
+// inside Data.Row to highlight the match (see HighlightMarkup). It returns
+// nil if the candidate wasn't ranked, e.g. because no Ranker is set or the
+// candidate doesn't implement Matchable.
+func MatchesFromContext(ctx context.Context) []int {
+	matches, _ := ctx.Value(matchesCtx).([]int)
+	return matches
+}
+
 // Data represents a data structure capable of being displayed inside a list by
 // constructing a new ListBoxRow.
 type Data interface {
@@ -85,14 +111,29 @@ type Autocompleter struct {
 
 	onSelects []SelectedFunc
 
-	popover  *gtk.Popover
-	listBox  *gtk.ListBox
-	listRows []row
-
-	searchers map[rune]Searcher
+	popover     *gtk.Popover
+	popoverBox  *gtk.Box
+	layerWindow *gtk.Window
+	mode        PresentationMode
+	listBox     *gtk.ListBox
+	listRows    []row
+
+	categoryBox     *gtk.Box
+	categories      []categoryChip
+	activeCategory  string
+	lastCategory    map[Searcher]string
+	activeSearchers []Searcher
+	allResults      collectedResults
+	lastQuery       string
+	lastCtx         context.Context
+
+	searchers map[rune][]Searcher
+	ranker    Ranker
 
 	parent         context.Context
 	cancel         context.CancelFunc
+	searchCancel   context.CancelFunc
+	generation     int
 	minChars       int
 	timeout        time.Duration
 	poppedUp       bool
@@ -115,6 +156,9 @@ var _ = cssutil.WriteCSS(`
 	.autocomplete-row label:nth-child(2) {
 		margin-top: -2px;
 	}
+	.autocomplete-categories {
+		padding: 4px 6px;
+	}
 `)
 
 // AutocompleterWidth is the minimum width of the popped up autocompleter.
@@ -142,31 +186,46 @@ func New(ctx context.Context, text *gtk.TextView) *Autocompleter {
 	scroll.SetMaxContentHeight(250)
 	scroll.SetPropagateNaturalHeight(true)
 
+	categoryBox := gtk.NewBox(gtk.OrientationHorizontal, 4)
+	categoryBox.AddCSSClass("autocomplete-categories")
+	categoryBox.SetVisible(false)
+
+	popoverBox := gtk.NewBox(gtk.OrientationVertical, 0)
+	popoverBox.Append(categoryBox)
+	popoverBox.Append(scroll)
+
 	popover := gtk.NewPopover()
 	popover.AddCSSClass("autocomplete-popover")
 	popover.SetSizeRequest(AutocompleterWidth, -1)
 	popover.SetParent(text)
-	popover.SetChild(scroll)
+	popover.SetChild(popoverBox)
 	popover.SetPosition(gtk.PosTop)
 	popover.SetAutohide(false)
 	popover.Hide()
 
 	ac := Autocompleter{
-		parent:    ctx,
-		tview:     text,
-		buffer:    text.Buffer(),
-		popover:   popover,
-		listBox:   list,
-		listRows:  make([]row, 0, MaxResults),
-		searchers: make(map[rune]Searcher),
-		onSelects: make([]SelectedFunc, 0, 1),
+		parent:       ctx,
+		tview:        text,
+		buffer:       text.Buffer(),
+		popover:      popover,
+		popoverBox:   popoverBox,
+		listBox:      list,
+		listRows:     make([]row, 0, MaxResults),
+		searchers:    make(map[rune][]Searcher),
+		ranker:       FuzzyRanker{},
+		onSelects:    make([]SelectedFunc, 0, 1),
+		categoryBox:  categoryBox,
+		lastCategory: make(map[Searcher]string),
 	}
 
 	text.ConnectUnmap(func() {
-		// Ensure the context is cleaned up.
+		// Ensure the contexts are cleaned up.
 		if ac.cancel != nil {
 			ac.cancel()
 		}
+		if ac.searchCancel != nil {
+			ac.searchCancel()
+		}
 	})
 
 	list.ConnectRowActivated(func(row *gtk.ListBoxRow) {
@@ -211,28 +270,41 @@ func (a *Autocompleter) SetTimeout(d time.Duration) {
 	a.timeout = d
 }
 
+// SetRanker sets the Ranker used to score and sort candidates once all
+// searchers triggered for the current word have reported in (or streamed a
+// new batch in, for an AsyncSearcher). The default is FuzzyRanker. Passing
+// nil displays candidates in the order their searchers returned them.
+func (a *Autocompleter) SetRanker(ranker Ranker) {
+	a.ranker = ranker
+}
+
 // AddSelectedFunc adds a callback that is called when the user has selected an
 // entry inside the autocompleter.
 func (a *Autocompleter) AddSelectedFunc(selectedFunc SelectedFunc) {
 	a.onSelects = append(a.onSelects, selectedFunc)
 }
 
-// Use registers the given searcher instance into the autocompleter.
+// Use registers the given searcher instances into the autocompleter. Multiple
+// searchers may share the same triggering rune (for example, a "@" user
+// searcher and an "@@" role searcher both triggering on '@'): all of them are
+// invoked for a word starting with that rune, and their results are merged
+// and ranked together.
 func (a *Autocompleter) Use(searchers ...Searcher) {
 	for _, s := range searchers {
-		if _, ok := a.searchers[s.Rune()]; ok {
-			log.Panicf("autocompleter: duplicate rune %q for searcher %T", s.Rune(), s)
-		}
-		a.searchers[s.Rune()] = s
+		r := s.Rune()
+		a.searchers[r] = append(a.searchers[r], s)
 	}
 }
 
 // Unuse removes the given searcher instance from the autocompleter using the
 // given identifying rune.
 func (a *Autocompleter) Unuse(searcher Searcher) {
-	for r, s := range a.searchers {
-		if s == searcher && r == searcher.Rune() {
-			delete(a.searchers, r)
+	r := searcher.Rune()
+
+	list := a.searchers[r]
+	for i, s := range list {
+		if s == searcher {
+			a.searchers[r] = append(list[:i], list[i+1:]...)
 			return
 		}
 	}
@@ -258,26 +330,26 @@ func (a *Autocompleter) Autocomplete() {
 	a.start = a.buffer.IterAtOffset(cursor)
 	a.end = a.buffer.IterAtOffset(cursor)
 
-	var searcher Searcher
+	var searchers []Searcher
 
 	if !a.start.BackwardFindChar(func(ch uint32) bool {
 		r := rune(ch)
 		if unicode.IsSpace(r) {
 			// If we stumbled upon a space character, then we haven't found
 			// anything yet inside a.searchers that resembles a non-whitespace
-			// rune, so we just grab one here.
-			searcher = a.searchers[WhitespaceRune]
+			// rune, so we just grab those here.
+			searchers = a.searchers[WhitespaceRune]
 			return true // stop scanning
 		}
 
 		var ok bool
-		searcher, ok = a.searchers[r]
+		searchers, ok = a.searchers[r]
 		return ok
-	}, nil) || searcher == nil {
+	}, nil) || len(searchers) == 0 {
 		// If we haven't managed to find anything and we're at the start of the
 		// line, then we probably want to use the WhitespaceRune as well.
-		if whitespaceSearcher, ok := a.searchers[WhitespaceRune]; ok {
-			searcher = whitespaceSearcher
+		if whitespaceSearchers, ok := a.searchers[WhitespaceRune]; ok {
+			searchers = whitespaceSearchers
 		} else {
 			a.hide()
 			return
@@ -317,19 +389,111 @@ func (a *Autocompleter) Autocomplete() {
 		End:   a.end,
 	})
 
-	searchCtx, cancel := context.WithTimeout(ctx, a.timeout)
-	defer cancel()
+	if a.searchCancel != nil {
+		a.searchCancel()
+	}
+
+	searchCtx, searchCancel := context.WithTimeout(ctx, a.timeout)
+	a.searchCancel = searchCancel
+
+	a.generation++
+	generation := a.generation
+	a.activeSearchers = searchers
+
+	var (
+		mu      sync.Mutex
+		results collectedResults
+	)
+
+	for _, searcher := range searchers {
+		searcher := searcher
+
+		if async, ok := searcher.(AsyncSearcher); ok {
+			go func() {
+				for data := range async.SearchAsync(searchCtx, text) {
+					mu.Lock()
+					results = results.append(searcher, data)
+					snapshot := results.clone()
+					mu.Unlock()
+
+					glib.IdleAdd(func() {
+						a.renderResults(ctx, generation, text, snapshot)
+					})
+				}
+			}()
+			continue
+		}
+
+		mu.Lock()
+		for _, data := range searcher.Search(searchCtx, text) {
+			results = results.append(searcher, data)
+		}
+		mu.Unlock()
+	}
+
+	mu.Lock()
+	snapshot := results.clone()
+	mu.Unlock()
+
+	a.renderResults(ctx, generation, text, snapshot)
+}
+
+// renderResults repopulates the popover's list box and category bar with
+// results, ranked against query. It is called once synchronously per
+// Autocomplete call, and again for every batch an AsyncSearcher streams in
+// afterwards; generation guards against a stale batch from an earlier word
+// overwriting a newer one.
+func (a *Autocompleter) renderResults(ctx context.Context, generation int, query string, results collectedResults) {
+	if generation != a.generation {
+		return
+	}
+
+	a.allResults = results
+	a.lastQuery = query
+	a.lastCtx = ctx
 
-	results := searcher.Search(searchCtx, text)
 	if len(results) == 0 {
+		a.clear()
+		a.categoryBox.SetVisible(false)
+		a.hide()
+		return
+	}
+
+	a.updateCategoryBar(results)
+	a.populate(ctx, query, results.filterCategory(a.activeCategory))
+}
+
+// populate repopulates the list box with filtered, ranked against query.
+func (a *Autocompleter) populate(ctx context.Context, query string, filtered collectedResults) {
+	a.clear()
+
+	plainData := make([]Data, len(filtered))
+	for i, entry := range filtered {
+		plainData[i] = entry.data
+	}
+
+	ranked := plainData
+	if a.ranker != nil {
+		ranked = asData(a.ranker.Rank(query, plainData, MaxResults))
+	} else if len(ranked) > MaxResults {
+		ranked = ranked[:MaxResults]
+	}
+
+	if len(ranked) == 0 {
 		a.hide()
 		return
 	}
 
-	for _, result := range results {
+	for _, result := range ranked {
+		rowCtx, data := ctx, result
+		if rd, ok := result.(RankedData); ok {
+			rowCtx = context.WithValue(ctx, matchesCtx, rd.Matches)
+			data = rd.Data
+		}
+
 		r := row{
-			ListBoxRow: result.Row(ctx),
-			data:       result,
+			ListBoxRow: data.Row(rowCtx),
+			data:       data,
 		}
 
 		r.AddCSSClass("autocomplete-row")
@@ -344,7 +508,13 @@ func (a *Autocompleter) Autocomplete() {
 
 // IsVisible returns true if the popover is currently visible.
 func (a *Autocompleter) IsVisible() bool {
-	return len(a.listRows) > 0 && a.popover.IsVisible()
+	if len(a.listRows) == 0 {
+		return false
+	}
+	if a.mode == PresentationLayerShell {
+		return a.layerWindow != nil && a.layerWindow.IsVisible()
+	}
+	return a.popover.IsVisible()
 }
 
 // Select selects the current Autocompleter entry.
@@ -388,25 +558,40 @@ func (a *Autocompleter) Clear() bool {
 }
 
 func (a *Autocompleter) hide() {
-	if a.poppedUp {
-		a.popover.Popdown()
-		a.poppedUp = false
+	if !a.poppedUp {
+		return
+	}
+	a.poppedUp = false
+
+	if a.mode == PresentationLayerShell {
+		if a.layerWindow != nil {
+			a.layerWindow.SetVisible(false)
+		}
+		return
 	}
+
+	a.popover.Popdown()
 }
 
 func (a *Autocompleter) show() {
-	if !a.poppedUp {
-		a.poppedUp = true
+	if a.poppedUp {
+		return
+	}
+	a.poppedUp = true
 
-		// Put the popover at the start of the word so we can avoid shifting the
-		// popover, otherwise it gets a bit annoying.
-		rect := a.tview.IterLocation(a.start)
-		x, y := a.tview.BufferToWindowCoords(gtk.TextWindowWidget, rect.X(), rect.Y())
+	// Put the popover at the start of the word so we can avoid shifting the
+	// popover, otherwise it gets a bit annoying.
+	rect := a.tview.IterLocation(a.start)
+	x, y := a.tview.BufferToWindowCoords(gtk.TextWindowWidget, rect.X(), rect.Y())
 
-		ptTo := gdk.NewRectangle(x, y, 1, 1)
-		a.popover.SetPointingTo(&ptTo)
-		a.popover.Popup()
+	if a.mode == PresentationLayerShell {
+		a.showLayerShell(x, y)
+		return
 	}
+
+	ptTo := gdk.NewRectangle(x, y, 1, 1)
+	a.popover.SetPointingTo(&ptTo)
+	a.popover.Popup()
 }
 
 func (a *Autocompleter) clear() {
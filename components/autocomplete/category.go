@@ -0,0 +1,218 @@
+package autocomplete
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// CategorizedSearcher is an optional interface a Searcher may implement to
+// group its results into named categories, displayed as a row of chips atop
+// the popover. If at least one of the searchers triggered for a word
+// implements this interface, the popover shows one chip per distinct
+// category and filters the visible list down to the selected one; results
+// from searchers that don't implement it are hidden once that happens, so
+// give every relevant Data a category.
+type CategorizedSearcher interface {
+	Searcher
+	// Category returns the category name and an optional icon name for data,
+	// which was previously returned by this searcher's Search or
+	// SearchAsync.
+	Category(data Data) (category, icon string)
+}
+
+// categoryChip is a single category button shown in the popover's category
+// bar.
+type categoryChip struct {
+	name string
+	icon string
+	btn  *gtk.ToggleButton
+}
+
+// collectedEntry pairs a search result with the searcher that produced it,
+// so its category can be looked up lazily without having to carry the
+// category alongside every Data value.
+type collectedEntry struct {
+	searcher Searcher
+	data     Data
+}
+
+// categoryIcon returns the entry's category and icon, or two empty strings if
+// its searcher isn't a CategorizedSearcher.
+func (e collectedEntry) categoryIcon() (category, icon string) {
+	cs, ok := e.searcher.(CategorizedSearcher)
+	if !ok {
+		return "", ""
+	}
+	return cs.Category(e.data)
+}
+
+// collectedResults accumulates search results across every searcher
+// triggered for the current word.
+type collectedResults []collectedEntry
+
+func (r collectedResults) append(searcher Searcher, data Data) collectedResults {
+	return append(r, collectedEntry{searcher: searcher, data: data})
+}
+
+// clone returns a copy of r safe to hand off to the main loop while r keeps
+// being appended to from a search goroutine.
+func (r collectedResults) clone() collectedResults {
+	clone := make(collectedResults, len(r))
+	copy(clone, r)
+	return clone
+}
+
+// filterCategory returns the subset of r belonging to category. An empty
+// category returns r unchanged.
+func (r collectedResults) filterCategory(category string) collectedResults {
+	if category == "" {
+		return r
+	}
+
+	filtered := make(collectedResults, 0, len(r))
+	for _, entry := range r {
+		if c, _ := entry.categoryIcon(); c == category {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	return filtered
+}
+
+// updateCategoryBar rebuilds the category chip row from results and picks
+// the active category, preferring the previously selected one, falling back
+// to the last-used category of the first current searcher, then the first
+// category seen.
+func (a *Autocompleter) updateCategoryBar(results collectedResults) {
+	seen := make(map[string]bool, 4)
+	chips := make([]categoryChip, 0, 4)
+
+	for _, entry := range results {
+		category, icon := entry.categoryIcon()
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		chips = append(chips, categoryChip{name: category, icon: icon})
+	}
+
+	a.categories = chips
+
+	if len(chips) == 0 {
+		a.categoryBox.SetVisible(false)
+		a.activeCategory = ""
+		return
+	}
+
+	active := a.activeCategory
+	if !seen[active] {
+		active = ""
+		if len(a.activeSearchers) > 0 {
+			active = a.lastCategory[a.activeSearchers[0]]
+		}
+		if !seen[active] {
+			active = chips[0].name
+		}
+	}
+	a.activeCategory = active
+
+	clearBox(a.categoryBox)
+
+	var group *gtk.ToggleButton
+	for i, chip := range chips {
+		chip := chip
+
+		btn := gtk.NewToggleButton()
+		btn.AddCSSClass("autocomplete-category")
+		btn.SetLabel(chip.name)
+		if chip.icon != "" {
+			btn.SetTooltipText(chip.name)
+		}
+
+		if group == nil {
+			group = btn
+		} else {
+			btn.SetGroup(group)
+		}
+
+		btn.SetActive(chip.name == active)
+		btn.ConnectToggled(func() {
+			if btn.Active() {
+				a.selectCategory(chip.name)
+			}
+		})
+
+		chips[i].btn = btn
+		a.categoryBox.Append(btn)
+	}
+
+	a.categoryBox.SetVisible(true)
+}
+
+// selectCategory switches the visible list to category, remembering it
+// against the first of the searchers currently in play so it's restored next
+// time that searcher triggers first.
+func (a *Autocompleter) selectCategory(category string) {
+	if category == a.activeCategory {
+		return
+	}
+
+	a.activeCategory = category
+	if len(a.activeSearchers) > 0 {
+		a.lastCategory[a.activeSearchers[0]] = category
+	}
+
+	for _, chip := range a.categories {
+		if chip.btn == nil {
+			continue
+		}
+		chip.btn.SetActive(chip.name == category)
+	}
+
+	a.populate(a.lastCtx, a.lastQuery, a.allResults.filterCategory(category))
+}
+
+// MoveLeft selects the category chip before the currently active one,
+// wrapping around. It does nothing if no category bar is showing. The
+// embedding app may bind this to Ctrl+Left or Shift+Tab.
+func (a *Autocompleter) MoveLeft() bool { return a.moveCategory(false) }
+
+// MoveRight selects the category chip after the currently active one,
+// wrapping around. It does nothing if no category bar is showing. The
+// embedding app may bind this to Ctrl+Right or Tab.
+func (a *Autocompleter) MoveRight() bool { return a.moveCategory(true) }
+
+func (a *Autocompleter) moveCategory(forward bool) bool {
+	if len(a.categories) < 2 || !a.IsVisible() {
+		return false
+	}
+
+	ix := -1
+	for i, chip := range a.categories {
+		if chip.name == a.activeCategory {
+			ix = i
+			break
+		}
+	}
+	if ix == -1 {
+		return false
+	}
+
+	if forward {
+		ix = (ix + 1) % len(a.categories)
+	} else {
+		ix = (ix - 1 + len(a.categories)) % len(a.categories)
+	}
+
+	a.selectCategory(a.categories[ix].name)
+
+	return true
+}
+
+// clearBox removes every child widget from box.
+func clearBox(box *gtk.Box) {
+	for child := box.FirstChild(); child != nil; {
+		next := child.NextSibling()
+		box.Remove(child)
+		child = next
+	}
+}
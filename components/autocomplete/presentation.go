@@ -0,0 +1,110 @@
+package autocomplete
+
+import (
+	"github.com/diamondburned/gotk4-layer-shell/pkg/layershell"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+)
+
+// PresentationMode selects how the autocomplete popover is rendered.
+type PresentationMode uint8
+
+const (
+	// PresentationPopover renders the autocompleter as a gtk.Popover
+	// parented to the TextView. This is the default, and works everywhere.
+	PresentationPopover PresentationMode = iota
+	// PresentationLayerShell renders the autocompleter as a separate
+	// gtk.Window positioned via gtk4-layer-shell, anchored to the TextView's
+	// cursor rectangle. Use this when the TextView lives inside a
+	// wlr-layer-shell surface (e.g. an overlay-style launcher), where a
+	// gtk.Popover's placement relative to its transient parent is unreliable.
+	PresentationLayerShell
+)
+
+// DetectPresentationMode returns PresentationLayerShell if window is itself a
+// wlr-layer-shell surface, and PresentationPopover otherwise. Call it once
+// the embedding app's window is realized, and pass the result to
+// SetPresentationMode.
+func DetectPresentationMode(window *gtk.Window) PresentationMode {
+	if layershell.IsSupported() && layershell.IsLayerWindow(window) {
+		return PresentationLayerShell
+	}
+	return PresentationPopover
+}
+
+// SetPresentationMode sets how the autocomplete popover is rendered. It may
+// be called at any time, including while the popover is visible.
+func (a *Autocompleter) SetPresentationMode(mode PresentationMode) {
+	if a.mode == mode {
+		return
+	}
+
+	wasVisible := a.poppedUp
+	a.hide()
+	a.mode = mode
+
+	switch mode {
+	case PresentationLayerShell:
+		a.ensureLayerWindow()
+		a.popover.SetChild(nil)
+		a.layerWindow.SetChild(a.popoverBox)
+	default:
+		if a.layerWindow != nil {
+			a.layerWindow.SetChild(nil)
+		}
+		a.popover.SetChild(a.popoverBox)
+	}
+
+	if wasVisible {
+		a.show()
+	}
+}
+
+// ensureLayerWindow lazily builds the layer-shell surface used by
+// PresentationLayerShell.
+func (a *Autocompleter) ensureLayerWindow() {
+	if a.layerWindow != nil {
+		return
+	}
+
+	win := gtk.NewWindow()
+	win.SetDecorated(false)
+	win.AddCSSClass("autocomplete-popover")
+	win.SetTransientFor(app.GTKWindowFromContext(a.parent))
+
+	layershell.InitForWindow(win)
+	layershell.SetLayer(win, layershell.LayerOverlay)
+	layershell.SetKeyboardMode(win, layershell.KeyboardModeOnDemand)
+	layershell.SetAnchor(win, layershell.EdgeTop, true)
+	layershell.SetAnchor(win, layershell.EdgeLeft, true)
+
+	focusCtrl := gtk.NewEventControllerFocus()
+	focusCtrl.ConnectLeave(func() {
+		a.hide()
+	})
+	win.AddController(focusCtrl)
+
+	keyCtrl := gtk.NewEventControllerKey()
+	keyCtrl.ConnectKeyPressed(func(keyval, _ uint, _ gdk.ModifierType) bool {
+		if keyval != gdk.KEY_Escape {
+			return false
+		}
+		a.hide()
+		return true
+	})
+	win.AddController(keyCtrl)
+
+	a.layerWindow = win
+}
+
+// showLayerShell presents the layer-shell surface anchored to the word's
+// on-screen position (x, y), given in a.tview's own window coordinates.
+func (a *Autocompleter) showLayerShell(x, y int) {
+	rootX, rootY := a.tview.TranslateCoordinates(app.GTKWindowFromContext(a.parent), float64(x), float64(y))
+
+	layershell.SetMargin(a.layerWindow, layershell.EdgeLeft, int(rootX))
+	layershell.SetMargin(a.layerWindow, layershell.EdgeTop, int(rootY))
+
+	a.layerWindow.Present()
+}
@@ -0,0 +1,236 @@
+package autocomplete
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// Matchable is implemented by Data that wants to participate in fuzzy
+// ranking. Data that doesn't implement Matchable is always kept in its
+// original search order, after every scored candidate.
+type Matchable interface {
+	Data
+	// MatchText returns the text that the query is matched against.
+	MatchText() string
+}
+
+// RankedData is a Data scored against a search query by a Ranker.
+type RankedData struct {
+	Data
+	// Score is the ranker's match score; higher is a better match.
+	Score int
+	// Matches holds, in order, the rune indices into the candidate's
+	// MatchText that matched the query. It is nil for unranked candidates.
+	// Retrieve it inside Data.Row via MatchesFromContext.
+	Matches []int
+}
+
+// Ranker scores and sorts candidate Data against a query string.
+type Ranker interface {
+	// Rank scores candidates against query and returns up to max of them,
+	// best match first.
+	Rank(query string, candidates []Data, max int) []RankedData
+}
+
+// asData downgrades a slice of RankedData back to Data, preserving order.
+// RankedData embeds Data, so each element already satisfies the interface.
+func asData(ranked []RankedData) []Data {
+	data := make([]Data, len(ranked))
+	for i, r := range ranked {
+		data[i] = r
+	}
+	return data
+}
+
+// FuzzyRanker is the default Ranker. It's a simplified fzf-style subsequence
+// matcher: the query's runes don't need to be contiguous in the candidate,
+// but matches at a word boundary, at a camelCase hump, or immediately
+// following the previous match score higher, while gaps between matches are
+// penalized.
+type FuzzyRanker struct{}
+
+const (
+	matchBonus       = 1
+	boundaryBonus    = 16
+	camelCaseBonus   = 8
+	consecutiveBonus = 4
+	firstGapPenalty  = -3
+	gapPenalty       = -1
+)
+
+// Rank implements Ranker. Candidates that don't implement Matchable, and every
+// candidate when query is empty, are appended unscored in their original
+// order after every scored match. Matchable candidates whose MatchText
+// doesn't contain query as a subsequence are dropped.
+func (FuzzyRanker) Rank(query string, candidates []Data, max int) []RankedData {
+	ranked := make([]RankedData, 0, len(candidates))
+	unmatched := make([]RankedData, 0)
+
+	for _, c := range candidates {
+		m, ok := c.(Matchable)
+		if !ok {
+			unmatched = append(unmatched, RankedData{Data: c})
+			continue
+		}
+
+		if query == "" {
+			unmatched = append(unmatched, RankedData{Data: c})
+			continue
+		}
+
+		score, matches, ok := fuzzyScore(query, m.MatchText())
+		if !ok {
+			continue
+		}
+
+		ranked = append(ranked, RankedData{Data: c, Score: score, Matches: matches})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+
+	ranked = append(ranked, unmatched...)
+	if len(ranked) > max {
+		ranked = ranked[:max]
+	}
+
+	return ranked
+}
+
+// fuzzyScore computes the best-scoring subsequence alignment of query inside
+// candidate. It reports ok == false if query isn't a subsequence of
+// candidate at all.
+func fuzzyScore(query, candidate string) (score int, matches []int, ok bool) {
+	q := []rune(strings.ToLower(query))
+	c := []rune(candidate)
+	cLower := []rune(strings.ToLower(candidate))
+
+	m, n := len(q), len(c)
+	if m == 0 || n == 0 || m > n {
+		return 0, nil, false
+	}
+
+	const negInf = -1 << 30
+
+	// dp[i][j] is the best score of matching q[:i] against c[:j], where the
+	// i-th query rune is matched at candidate position j-1. from[i][j] holds
+	// the predecessor position used to get there, for backtracking.
+	dp := make([][]int, m+1)
+	from := make([][]int, m+1)
+	for i := range dp {
+		dp[i] = make([]int, n+1)
+		from[i] = make([]int, n+1)
+		for j := range dp[i] {
+			dp[i][j] = negInf
+		}
+	}
+
+	for i := 1; i <= m; i++ {
+		for j := i; j <= n; j++ {
+			if cLower[j-1] != q[i-1] {
+				continue
+			}
+
+			bonus := matchBonus
+			switch {
+			case j == 1 || isBoundary(c[j-2]):
+				bonus += boundaryBonus
+			case unicode.IsUpper(c[j-1]) && unicode.IsLower(c[j-2]):
+				bonus += camelCaseBonus
+			}
+
+			if i == 1 {
+				dp[i][j] = bonus
+				from[i][j] = 0
+				continue
+			}
+
+			best, bestFrom := negInf, -1
+			for k := i - 1; k < j; k++ {
+				if dp[i-1][k] == negInf {
+					continue
+				}
+
+				gap := j - k - 1
+
+				s := dp[i-1][k] + bonus
+				switch {
+				case gap == 0:
+					s += consecutiveBonus
+				case gap == 1:
+					s += firstGapPenalty
+				default:
+					s += firstGapPenalty + (gap-1)*gapPenalty
+				}
+
+				if s > best {
+					best, bestFrom = s, k
+				}
+			}
+
+			dp[i][j] = best
+			from[i][j] = bestFrom
+		}
+	}
+
+	bestScore, bestJ := negInf, -1
+	for j := m; j <= n; j++ {
+		if dp[m][j] > bestScore {
+			bestScore, bestJ = dp[m][j], j
+		}
+	}
+
+	if bestJ == -1 {
+		return 0, nil, false
+	}
+
+	matches = make([]int, m)
+	i, j := m, bestJ
+	for i > 0 {
+		matches[i-1] = j - 1
+		j = from[i][j]
+		i--
+	}
+
+	return bestScore, matches, true
+}
+
+func isBoundary(prev rune) bool {
+	switch prev {
+	case ' ', '_', '-', '.', '/', '@', '#':
+		return true
+	default:
+		return false
+	}
+}
+
+// HighlightMarkup wraps the runes of text at the given matched indices (as
+// returned inside RankedData.Matches, or MatchesFromContext) in Pango <b>
+// markup, escaping the rest so the result is safe to use as the label text
+// of a gtk.Label with UseMarkup enabled.
+func HighlightMarkup(text string, matches []int) string {
+	if len(matches) == 0 {
+		return glib.MarkupEscapeText(text, -1)
+	}
+
+	matchSet := make(map[int]bool, len(matches))
+	for _, m := range matches {
+		matchSet[m] = true
+	}
+
+	var b strings.Builder
+	for i, r := range []rune(text) {
+		escaped := glib.MarkupEscapeText(string(r), -1)
+		if matchSet[i] {
+			b.WriteString("<b>")
+			b.WriteString(escaped)
+			b.WriteString("</b>")
+		} else {
+			b.WriteString(escaped)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package autocomplete
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// testMatchable is a minimal Matchable used to exercise FuzzyRanker without
+// needing a real row widget.
+type testMatchable struct{ text string }
+
+func (testMatchable) Row(context.Context) *gtk.ListBoxRow { panic("not implemented") }
+func (d testMatchable) MatchText() string                 { return d.text }
+
+// testUnmatchable implements Data but not Matchable.
+type testUnmatchable struct{}
+
+func (testUnmatchable) Row(context.Context) *gtk.ListBoxRow { panic("not implemented") }
+
+func TestFuzzyScore(t *testing.T) {
+	t.Run("prefix match", func(t *testing.T) {
+		score, matches, ok := fuzzyScore("abc", "abcdef")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		if want := []int{0, 1, 2}; !equalInts(matches, want) {
+			t.Errorf("matches = %v, want %v", matches, want)
+		}
+		if score <= 0 {
+			t.Errorf("score = %d, want positive", score)
+		}
+	})
+
+	t.Run("not a subsequence is rejected", func(t *testing.T) {
+		if _, _, ok := fuzzyScore("xyz", "golang"); ok {
+			t.Error("want ok == false for a non-subsequence query")
+		}
+	})
+
+	t.Run("empty query or candidate is rejected", func(t *testing.T) {
+		if _, _, ok := fuzzyScore("", "golang"); ok {
+			t.Error("want ok == false for an empty query")
+		}
+		if _, _, ok := fuzzyScore("g", ""); ok {
+			t.Error("want ok == false for an empty candidate")
+		}
+	})
+
+	t.Run("camelCase hump scores higher than a plain match", func(t *testing.T) {
+		camelCase, _, ok := fuzzyScore("f", "myFile")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		plain, _, ok := fuzzyScore("f", "myfile")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		if camelCase <= plain {
+			t.Errorf("camelCase score = %d, want > plain score %d", camelCase, plain)
+		}
+	})
+
+	t.Run("a consecutive run scores higher than the same match with a gap", func(t *testing.T) {
+		consecutive, _, ok := fuzzyScore("ab", "abx")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		gapped, _, ok := fuzzyScore("ab", "axxxb")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		if consecutive <= gapped {
+			t.Errorf("consecutive score = %d, want > gapped score %d", consecutive, gapped)
+		}
+	})
+
+	t.Run("a smaller gap scores higher than a larger one", func(t *testing.T) {
+		smallGap, _, ok := fuzzyScore("ab", "axb")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		largeGap, _, ok := fuzzyScore("ab", "axxxb")
+		if !ok {
+			t.Fatal("want ok")
+		}
+		if smallGap <= largeGap {
+			t.Errorf("small-gap score = %d, want > large-gap score %d", smallGap, largeGap)
+		}
+	})
+}
+
+func TestFuzzyRankerRank(t *testing.T) {
+	candidates := []Data{
+		testMatchable{"foobar"},   // matches "fb", no camelCase hump
+		testMatchable{"FooBar"},   // matches "fb" with a camelCase hump on B
+		testMatchable{"xyz"},      // "fb" is not a subsequence of it, dropped entirely
+		testUnmatchable{},         // not Matchable, kept but unscored
+	}
+
+	ranked := FuzzyRanker{}.Rank("fb", candidates, 10)
+
+	if len(ranked) != 3 {
+		t.Fatalf("got %d results, want 3 (one dropped): %+v", len(ranked), ranked)
+	}
+
+	if got := ranked[0].Data.(testMatchable).text; got != "FooBar" {
+		t.Errorf("best match = %q, want %q", got, "FooBar")
+	}
+	if got := ranked[1].Data.(testMatchable).text; got != "foobar" {
+		t.Errorf("second match = %q, want %q", got, "foobar")
+	}
+	if _, ok := ranked[2].Data.(testUnmatchable); !ok {
+		t.Errorf("last result = %+v, want the unmatched candidate", ranked[2])
+	}
+	if ranked[2].Score != 0 || ranked[2].Matches != nil {
+		t.Errorf("unmatched candidate got scored: %+v", ranked[2])
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
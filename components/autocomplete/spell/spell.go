@@ -0,0 +1,195 @@
+// Package spell provides gspell-based inline spellchecking for a TextView
+// used by the autocomplete package. It underlines misspelled words in the
+// buffer and exposes a SpellSuggestionSearcher that surfaces dictionary
+// suggestions through the existing autocomplete popover.
+package spell
+
+import (
+	"context"
+	"unicode"
+
+	"github.com/diamondburned/chatkit/components/autocomplete"
+	"github.com/diamondburned/gotk4-gspell/pkg/gspell"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+// Checker attaches a gspell Checker to a TextView's buffer, underlining
+// misspelled words as the user types.
+type Checker struct {
+	view    *gspell.TextView
+	buffer  *gspell.TextBuffer
+	checker *gspell.Checker
+	tview   *gtk.TextView
+}
+
+// NewChecker creates a new Checker and attaches it to the given TextView.
+// The initial language is gspell's default, which follows the user's locale.
+func NewChecker(ctx context.Context, tview *gtk.TextView) *Checker {
+	checker := gspell.NewChecker(nil)
+
+	buffer := gspell.TextBufferGet(tview.Buffer())
+	buffer.SetSpellChecker(checker)
+
+	view := gspell.TextViewGet(tview)
+	view.SetEnableLanguageMenu(true)
+	// BasicSetup wires up the underline attributes and the context menu,
+	// including the "Add to Dictionary" and "Ignore All" entries.
+	view.BasicSetup()
+
+	return &Checker{
+		view:    view,
+		buffer:  buffer,
+		checker: checker,
+		tview:   tview,
+	}
+}
+
+// SetLanguage sets the dictionary language used for spellchecking, e.g.
+// "en_US". It is a no-op if the language isn't known to gspell.
+func (c *Checker) SetLanguage(code string) {
+	language := gspell.LanguageLookup(code)
+	if language == nil {
+		return
+	}
+	c.checker.SetLanguage(language)
+}
+
+// Language returns the code of the checker's currently active language, or
+// an empty string if none is set.
+func (c *Checker) Language() string {
+	language := c.checker.Language()
+	if language == nil {
+		return ""
+	}
+	return language.Code()
+}
+
+// AddWordToDictionary permanently adds word to the user's personal
+// dictionary, so it is no longer flagged as misspelled.
+func (c *Checker) AddWordToDictionary(word string) {
+	c.checker.AddWordToPersonal(word, -1)
+}
+
+// IgnoreWord ignores word for the remainder of the session without adding it
+// to the personal dictionary.
+func (c *Checker) IgnoreWord(word string) {
+	c.checker.IgnoreWord(word, -1)
+}
+
+// SpellSuggestionSearcher implements autocomplete.Searcher, surfacing
+// dictionary suggestions for the misspelled word under the cursor.
+type SpellSuggestionSearcher struct {
+	checker *Checker
+}
+
+// NewSpellSuggestionSearcher creates a new SpellSuggestionSearcher backed by
+// checker.
+func NewSpellSuggestionSearcher(checker *Checker) *SpellSuggestionSearcher {
+	return &SpellSuggestionSearcher{checker: checker}
+}
+
+// Attach registers the searcher on ac and wires up replacement of the
+// misspelled word when a suggestion is selected.
+func (s *SpellSuggestionSearcher) Attach(ac *autocomplete.Autocompleter) {
+	ac.Use(s)
+	ac.AddSelectedFunc(s.onSelected)
+}
+
+// Rune implements autocomplete.Searcher. Spellchecking applies to every word,
+// so the searcher is triggered on autocomplete.WhitespaceRune.
+func (s *SpellSuggestionSearcher) Rune() rune { return autocomplete.WhitespaceRune }
+
+// Search implements autocomplete.Searcher. It returns no results for
+// correctly-spelled words. Leading/trailing punctuation (e.g. a trailing
+// comma or question mark) is trimmed off before checking, since word is cut
+// on whitespace and gspell matches exactly.
+func (s *SpellSuggestionSearcher) Search(ctx context.Context, word string) []autocomplete.Data {
+	trimmed, leading, trailing := trimNonLetters(word)
+	if trimmed == "" || s.checker.checker.CheckWord(trimmed, -1) {
+		return nil
+	}
+
+	suggestions := s.checker.checker.Suggestions(trimmed, -1)
+	if len(suggestions) > autocomplete.MaxResults {
+		suggestions = suggestions[:autocomplete.MaxResults]
+	}
+
+	data := make([]autocomplete.Data, len(suggestions))
+	for i, suggestion := range suggestions {
+		data[i] = suggestionData{word: suggestion, leading: leading, trailing: trailing}
+	}
+
+	return data
+}
+
+// trimNonLetters strips leading/trailing runes that aren't letters from word,
+// returning the trimmed word along with how many runes were cut off each end.
+func trimNonLetters(word string) (trimmed string, leading, trailing int) {
+	runes := []rune(word)
+
+	start := 0
+	for start < len(runes) && !unicode.IsLetter(runes[start]) {
+		start++
+	}
+
+	end := len(runes)
+	for end > start && !unicode.IsLetter(runes[end-1]) {
+		end--
+	}
+
+	return string(runes[start:end]), start, len(runes) - end
+}
+
+func (s *SpellSuggestionSearcher) onSelected(data autocomplete.SelectedData) bool {
+	suggestion, ok := data.Data.(suggestionData)
+	if !ok {
+		return false
+	}
+
+	start := data.Bounds[0].Copy()
+	// Bounds[0] sits on the triggering whitespace rune, if any, which isn't
+	// part of the word that was searched.
+	if unicode.IsSpace(rune(start.Char())) {
+		start.ForwardChar()
+	}
+	start.ForwardChars(suggestion.leading)
+
+	end := data.Bounds[1].Copy()
+	end.BackwardChars(suggestion.trailing)
+
+	buffer := s.checker.tview.Buffer()
+	buffer.Delete(start, end)
+	buffer.Insert(end, suggestion.word)
+
+	return true
+}
+
+// suggestionData is a single spelling suggestion rendered as a row inside the
+// autocomplete popover. leading and trailing are the counts of non-letter
+// runes trimmed off the original word, so onSelected can narrow the replaced
+// range to the letter span and leave surrounding punctuation untouched.
+type suggestionData struct {
+	word     string
+	leading  int
+	trailing int
+}
+
+// Row implements autocomplete.Data.
+func (d suggestionData) Row(context.Context) *gtk.ListBoxRow {
+	word := gtk.NewLabel(d.word)
+	word.SetXAlign(0)
+	word.SetHExpand(true)
+
+	action := gtk.NewLabel("replace")
+	action.AddCSSClass("dim-label")
+	action.SetXAlign(1)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	box.Append(word)
+	box.Append(action)
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+
+	return row
+}
@@ -2,6 +2,8 @@ package embed
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
@@ -9,13 +11,17 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"sync/atomic"
+	"time"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
 )
 
@@ -33,12 +39,19 @@ type Viewer struct {
 	ControlsStart ControlsBoxStart
 	ControlsEnd   ControlsBoxEnd
 
-	vadj  *gtk.Adjustment
-	hadj  *gtk.Adjustment
+	vadj *gtk.Adjustment
+	hadj *gtk.Adjustment
 
-	zoom float64
+	zoom     float64
+	rotation int // degrees: 0, 90, 180, or 270
 	filename string
 
+	downloadSHA256   string
+	maxDownloadBytes int64
+	progressBar      *gtk.ProgressBar
+
+	onZoomChanged []func(percent int)
+
 	ctx context.Context
 }
 
@@ -52,13 +65,46 @@ type ControlsBoxStart struct {
 
 type ControlsBoxEnd struct {
 	*gtk.Box
+
+	RotateLeft  *gtk.Button
+	RotateRight *gtk.Button
 }
 
+// Zoom bounds and step. Ctrl+scroll and the keyboard shortcuts nudge the zoom
+// by zoomStep per notch/press; pinch-zoom is unbounded within [minZoom,
+// maxZoom].
+const (
+	minZoom  = 0.05
+	maxZoom  = 16.0
+	zoomStep = 1.2
+)
+
+// defaultMaxDownloadBytes is the default cap on how much of a remote file
+// Download will pull down, so a server that lies about (or omits)
+// Content-Length can't exhaust disk. Override it with SetMaxDownloadBytes.
+const defaultMaxDownloadBytes = 1 << 30 // 1GiB
+
+// progressInterval is how often the download toast's progress text is
+// refreshed.
+const progressInterval = 250 * time.Millisecond
+
+var _ = cssutil.WriteCSS(`
+	.embed-viewer-embed.rotate-90 {
+		transform: rotate(90deg);
+	}
+	.embed-viewer-embed.rotate-180 {
+		transform: rotate(180deg);
+	}
+	.embed-viewer-embed.rotate-270 {
+		transform: rotate(270deg);
+	}
+`)
+
 var ControlsStyles = []string{"osd", "circular"}
 
 // NewViewer creates a new instance of Viewer window, representing an image viewer.
 func NewViewer(ctx context.Context, uri string, opts Opts) (*Viewer, error) {
-	v := Viewer{ctx: ctx}
+	v := Viewer{ctx: ctx, maxDownloadBytes: defaultMaxDownloadBytes}
 	v.Embed = New(ctx, 0, 0, opts)
 	v.Embed.SetFromURL(uri)
 
@@ -68,6 +114,12 @@ func NewViewer(ctx context.Context, uri string, opts Opts) (*Viewer, error) {
 
 	v.ToastOverlay.SetChild(v.Overlay)
 
+	v.progressBar = gtk.NewProgressBar()
+	v.progressBar.AddCSSClass("osd")
+	v.progressBar.SetValign(gtk.AlignStart)
+	v.progressBar.SetVisible(false)
+	v.Overlay.AddOverlay(v.progressBar)
+
 	v.Scroll = gtk.NewScrolledWindow()
 	v.Scroll.SetVExpand(true)
 	v.Scroll.SetHExpand(true)
@@ -118,9 +170,14 @@ func NewViewer(ctx context.Context, uri string, opts Opts) (*Viewer, error) {
 	v.ControlsStart.Append(v.ControlsStart.CopyURL)
 
 	v.ControlsEnd = ControlsBoxEnd{
-		Box: gtk.NewBox(gtk.OrientationHorizontal, 6),
+		Box:         gtk.NewBox(gtk.OrientationHorizontal, 6),
+		RotateLeft:  newActionButton(v, "Rotate Left", "object-rotate-left-symbolic", "embedviewer.rotate-left", ControlsStyles),
+		RotateRight: newActionButton(v, "Rotate Right", "object-rotate-right-symbolic", "embedviewer.rotate-right", ControlsStyles),
 	}
 
+	v.ControlsEnd.Append(v.ControlsEnd.RotateLeft)
+	v.ControlsEnd.Append(v.ControlsEnd.RotateRight)
+
 	v.ControlsEnd.SetMarginBottom(18)
 	v.ControlsEnd.SetMarginStart(18)
 	v.ControlsEnd.SetHAlign(gtk.AlignEnd)
@@ -140,10 +197,13 @@ func NewViewer(ctx context.Context, uri string, opts Opts) (*Viewer, error) {
 		"embedviewer.download":      v.download,
 		"embedviewer.copy-url":      v.copyURL,
 		"embedviewer.open-original": v.openOriginal,
+		"embedviewer.rotate-left":   func() { v.rotate(-90) },
+		"embedviewer.rotate-right":  func() { v.rotate(90) },
 	})
 
 	switch opts.Type {
 	case EmbedTypeImage, EmbedTypeGIF:
+		v.Embed.AddCSSClass("embed-viewer-embed")
 		v.Embed.SetHExpand(true)
 		v.Embed.SetVExpand(true)
 
@@ -176,6 +236,70 @@ func NewViewer(ctx context.Context, uri string, opts Opts) (*Viewer, error) {
 
 		v.Scroll.AddController(dragCtrl)
 
+		// Track the pointer so Ctrl+scroll can zoom about it.
+		var pointerX, pointerY float64
+
+		motionCtrl := gtk.NewEventControllerMotion()
+		motionCtrl.ConnectMotion(func(x, y float64) {
+			pointerX, pointerY = x, y
+		})
+		v.Scroll.AddController(motionCtrl)
+
+		scrollCtrl := gtk.NewEventControllerScroll(gtk.EventControllerScrollVertical)
+		scrollCtrl.ConnectScroll(func(_, dy float64) bool {
+			if !scrollCtrl.CurrentEventState().Has(gdk.ControlMask) {
+				return false
+			}
+
+			v.zoomAt(v.zoom*math.Pow(zoomStep, -dy), pointerX, pointerY)
+			return true
+		})
+		v.Scroll.AddController(scrollCtrl)
+
+		var zoomBase float64
+
+		zoomCtrl := gtk.NewGestureZoom()
+		zoomCtrl.ConnectBegin(func(*gdk.EventSequence) {
+			zoomBase = v.zoom
+		})
+		zoomCtrl.ConnectScaleChanged(func(scale float64) {
+			x, y, ok := zoomCtrl.BoundingBoxCenter()
+			if !ok {
+				return
+			}
+			v.zoomAt(zoomBase*scale, x, y)
+		})
+		v.Scroll.AddController(zoomCtrl)
+
+		rotateCtrl := gtk.NewGestureRotate()
+		var rotateBase int
+		rotateCtrl.ConnectBegin(func(*gdk.EventSequence) {
+			rotateBase = v.rotation
+		})
+		rotateCtrl.ConnectAngleChanged(func(angle, _ float64) {
+			degrees := rotateBase + int(angle*180/math.Pi)
+			v.setRotation(((degrees % 360) + 360) % 360)
+		})
+		v.Scroll.AddController(rotateCtrl)
+
+		keyCtrl := gtk.NewEventControllerKey()
+		keyCtrl.ConnectKeyPressed(func(keyval, _ uint, _ gdk.ModifierType) bool {
+			switch keyval {
+			case gdk.KEY_plus, gdk.KEY_equal, gdk.KEY_KP_Add:
+				v.SetZoom(v.zoom * zoomStep)
+			case gdk.KEY_minus, gdk.KEY_KP_Subtract:
+				v.SetZoom(v.zoom / zoomStep)
+			case gdk.KEY_0, gdk.KEY_KP_0:
+				v.ZoomToFit()
+			case gdk.KEY_1, gdk.KEY_KP_1:
+				v.ZoomActual()
+			default:
+				return false
+			}
+			return true
+		})
+		v.AddController(keyCtrl)
+
 	case EmbedTypeGIFV, EmbedTypeVideo:
 		v.Embed.SetVExpand(true)
 		v.Embed.SetHExpand(true)
@@ -184,6 +308,14 @@ func NewViewer(ctx context.Context, uri string, opts Opts) (*Viewer, error) {
 
 		v.Scroll.SetChild(v.Embed)
 		v.Scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyNever)
+
+		// Rotation is driven by the "rotate-*" CSS classes on
+		// "embed-viewer-embed", which only image/GIF embeds carry; hide just
+		// the rotate buttons instead of wiring up a rotation that would do
+		// nothing, since ControlsEnd may also hold buttons an embedding app
+		// added via AddEndButton.
+		v.ControlsEnd.RotateLeft.SetVisible(false)
+		v.ControlsEnd.RotateRight.SetVisible(false)
 	default:
 		err := fmt.Errorf("unsupported embed type: %#v", opts.Type)
 		return nil, err
@@ -208,6 +340,19 @@ func newActionButton(target gtk.Widgetter, text, icon, action string, styles []s
 	return button
 }
 
+// SetChecksum sets the expected SHA-256 checksum (hex-encoded) of the file at
+// the embed's URL. If set, Download verifies the downloaded bytes against it
+// and discards the file on mismatch.
+func (v *Viewer) SetChecksum(sha256Hex string) {
+	v.downloadSHA256 = sha256Hex
+}
+
+// SetMaxDownloadBytes overrides the default cap on how much of a remote file
+// Download will pull down. It defaults to 1GiB.
+func (v *Viewer) SetMaxDownloadBytes(n int64) {
+	v.maxDownloadBytes = n
+}
+
 // SetShowBackButton sets whether to show back button at the start of headerbar.
 func (v *Viewer) SetShowBackButton(show bool) {
 	if !show {
@@ -261,33 +406,262 @@ func (v *Viewer) download() {
 	chooser.Show()
 }
 
+// saveToFile streams pictureURL to file, reporting progress on a persistent
+// toast plus an overlay gtk.ProgressBar (adw.Toast has no slot for a child
+// widget, so the bar lives in the overlay instead), with a Cancel action on
+// the toast. The download is written to a "*.part" sibling of file and renamed
+// into place once it verifies, so a crash or cancel never leaves a truncated
+// file at the destination path. Re-downloads send both If-Modified-Since
+// (from the existing file's mtime) and If-None-Match (from the sidecar
+// ".etag" file saved by the previous download), so an unchanged file on the
+// server short-circuits to http.StatusNotModified either way.
 func (v *Viewer) saveToFile(file *gio.File, pictureURL string) {
 	outPath := file.Path()
+	partPath := outPath + ".part"
+	etagPath := outPath + ".etag"
 
-	response, err := http.Get(pictureURL)
+	ctx, cancel := context.WithCancel(v.ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pictureURL, nil)
 	if err != nil {
-		v.ToastOverlay.AddToast(adw.NewToast("An error occured while downloading picture data"))
-		fmt.Println("An error occured while downloading picture data:", err)
+		cancel()
+		v.downloadError("An error occurred while preparing the download", err)
 		return
 	}
-	defer response.Body.Close()
 
-	out, err := os.Create(outPath)
-	if err != nil {
-		v.ToastOverlay.AddToast(adw.NewToast("An I/O error occurred while creating the output file"))
-		fmt.Println("An I/O error occurred while creating the output file:", err)
-		return
+	if info, err := os.Stat(outPath); err == nil {
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	if etag, err := os.ReadFile(etagPath); err == nil {
+		req.Header.Set("If-None-Match", string(etag))
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, response.Body)
-	if err != nil {
-		v.ToastOverlay.AddToast(adw.NewToast("An I/O error occurred while saving the file"))
-		fmt.Println("An I/O error occurred while saving the file:", err)
-		return
+	toast := adw.NewToast("Downloading…")
+	toast.SetTimeout(0)
+	toast.SetButtonLabel("Cancel")
+	toast.ConnectButtonClicked(cancel)
+	v.ToastOverlay.AddToast(toast)
+
+	v.progressBar.SetFraction(0)
+	v.progressBar.SetVisible(true)
+
+	go func() {
+		defer cancel()
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				if ctx.Err() != nil {
+					v.downloadCancelled()
+				} else {
+					v.downloadError("An error occurred while downloading the picture", err)
+				}
+			})
+			return
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotModified:
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.ToastOverlay.AddToast(adw.NewToast("Already up to date"))
+			})
+			return
+		default:
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.downloadError("Unexpected response while downloading", fmt.Errorf("HTTP %s", resp.Status))
+			})
+			return
+		}
+
+		if resp.ContentLength > v.maxDownloadBytes {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.downloadError("Download too large", fmt.Errorf("%d bytes exceeds the %d byte limit", resp.ContentLength, v.maxDownloadBytes))
+			})
+			return
+		}
+
+		out, err := os.Create(partPath)
+		if err != nil {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.downloadError("An I/O error occurred while creating the output file", err)
+			})
+			return
+		}
+
+		keepPart := false
+		defer func() {
+			out.Close()
+			if !keepPart {
+				os.Remove(partPath)
+			}
+		}()
+
+		hash := sha256.New()
+		counter := &countingWriter{w: io.MultiWriter(out, hash)}
+
+		stop := make(chan struct{})
+		start := time.Now()
+		go func() {
+			ticker := time.NewTicker(progressInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					written := atomic.LoadInt64(&counter.n)
+					glib.IdleAdd(func() {
+						toast.SetTitle(downloadProgress(written, resp.ContentLength, time.Since(start)))
+						if resp.ContentLength > 0 {
+							v.progressBar.SetFraction(float64(written) / float64(resp.ContentLength))
+						} else {
+							v.progressBar.Pulse()
+						}
+					})
+				}
+			}
+		}()
+
+		written, err := io.Copy(counter, io.LimitReader(resp.Body, v.maxDownloadBytes+1))
+		close(stop)
+
+		if err != nil {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				if ctx.Err() != nil {
+					v.downloadCancelled()
+					return
+				}
+				v.downloadError("An I/O error occurred while saving the file", err)
+			})
+			return
+		}
+
+		if written > v.maxDownloadBytes {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.downloadError("Download too large", fmt.Errorf("exceeded the %d byte limit", v.maxDownloadBytes))
+			})
+			return
+		}
+
+		if v.downloadSHA256 != "" {
+			if sum := hex.EncodeToString(hash.Sum(nil)); sum != v.downloadSHA256 {
+				glib.IdleAdd(func() {
+					toast.Dismiss()
+					v.progressBar.SetVisible(false)
+					v.downloadError("Checksum mismatch", fmt.Errorf("got %s, want %s", sum, v.downloadSHA256))
+				})
+				return
+			}
+		}
+
+		if err := out.Close(); err != nil {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.downloadError("An I/O error occurred while saving the file", err)
+			})
+			return
+		}
+
+		if err := os.Rename(partPath, outPath); err != nil {
+			glib.IdleAdd(func() {
+				toast.Dismiss()
+				v.progressBar.SetVisible(false)
+				v.downloadError("An I/O error occurred while saving the file", err)
+			})
+			return
+		}
+		keepPart = true
+
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			// Best-effort: a failure to save the sidecar just means the next
+			// download won't send If-None-Match, not a lost file.
+			os.WriteFile(etagPath, []byte(etag), 0o644)
+		}
+
+		glib.IdleAdd(func() {
+			toast.Dismiss()
+			v.progressBar.SetVisible(false)
+			v.ToastOverlay.AddToast(adw.NewToast("Picture saved successfully"))
+		})
+	}()
+}
+
+// downloadError shows an error toast and logs err, matching the other
+// best-effort error reporting in this file.
+func (v *Viewer) downloadError(msg string, err error) {
+	v.ToastOverlay.AddToast(adw.NewToast(msg))
+	fmt.Println(msg+":", err)
+}
+
+// downloadCancelled shows a neutral toast for a download the user cancelled
+// themselves, so it isn't mistaken for downloadError's failure reporting.
+func (v *Viewer) downloadCancelled() {
+	v.ToastOverlay.AddToast(adw.NewToast("Download cancelled"))
+}
+
+// downloadProgress formats the persistent download toast's title, including
+// a percentage and ETA once total is known from Content-Length.
+func downloadProgress(written, total int64, elapsed time.Duration) string {
+	if total <= 0 {
+		return fmt.Sprintf("Downloading… %s", formatBytes(written))
 	}
 
-	v.ToastOverlay.AddToast(adw.NewToast("Picture saved successfully"))
+	percent := int(float64(written) / float64(total) * 100)
+
+	rate := float64(written) / elapsed.Seconds()
+	if rate <= 0 {
+		return fmt.Sprintf("Downloading… %d%%", percent)
+	}
+
+	eta := time.Duration(float64(total-written)/rate) * time.Second
+	return fmt.Sprintf("Downloading… %d%% (%s left)", percent, eta.Round(time.Second))
+}
+
+// formatBytes renders n as a human-readable byte size, e.g. "4.2 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// countingWriter wraps an io.Writer, tracking the total bytes written so a
+// separate goroutine can poll progress without racing the writer itself.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
 }
 
 func (v *Viewer) copyURL() {
@@ -322,3 +696,110 @@ func (v *Viewer) scaleFit() {
 	hscale := float64(vph) / float64(h)
 	v.zoom = math.Min(wscale, hscale)
 }
+
+// SetZoom sets the zoom level as a multiplier of the image's original size,
+// e.g. 1.0 is 100%. The point at the center of the viewport stays put.
+func (v *Viewer) SetZoom(zoom float64) {
+	vpw, vph := v.Scroll.Allocation().Width(), v.Scroll.Allocation().Height()
+	v.zoomAt(zoom, float64(vpw)/2, float64(vph)/2)
+}
+
+// Zoom returns the current zoom level as a multiplier of the image's
+// original size.
+func (v *Viewer) Zoom() float64 {
+	return v.zoom
+}
+
+// ZoomToFit resets the zoom so that the image fits within the viewport. This
+// is the zoom level used when the viewer is first opened.
+func (v *Viewer) ZoomToFit() {
+	v.scaleFit()
+	v.notifyZoomChanged()
+}
+
+// ZoomActual sets the zoom to 1:1, i.e. the image's original pixel size.
+func (v *Viewer) ZoomActual() {
+	v.zoom = 1.0
+	v.applyZoom()
+}
+
+// OnZoomChanged registers a callback invoked whenever the zoom level
+// changes, receiving the new zoom as a whole percentage (e.g. 150 for a
+// 1.5x zoom). This is useful for showing the current zoom in e.g. a
+// headerbar subtitle. It is not called for the automatic fit-to-window when
+// an image first loads; Zoom reports that level.
+func (v *Viewer) OnZoomChanged(f func(percent int)) {
+	v.onZoomChanged = append(v.onZoomChanged, f)
+}
+
+// zoomAt sets the zoom level to newZoom, keeping the point at (x, y)
+// viewport-relative coordinates stable under the cursor.
+func (v *Viewer) zoomAt(newZoom, x, y float64) {
+	newZoom = clampZoom(newZoom)
+	if newZoom == v.zoom {
+		return
+	}
+
+	scale := newZoom / v.zoom
+
+	newH := (v.hadj.Value()+x)*scale - x
+	newV := (v.vadj.Value()+y)*scale - y
+
+	v.zoom = newZoom
+	v.applyZoom()
+
+	v.hadj.SetValue(newH)
+	v.vadj.SetValue(newV)
+}
+
+// applyZoom resizes the Embed to match v.zoom and notifies zoom-changed
+// listeners.
+func (v *Viewer) applyZoom() {
+	w, h := v.Embed.Size()
+	v.Embed.SetSizeRequest(int(float64(w)*v.zoom), int(float64(h)*v.zoom))
+
+	v.notifyZoomChanged()
+}
+
+func (v *Viewer) notifyZoomChanged() {
+	percent := int(math.Round(v.zoom * 100))
+	for _, f := range v.onZoomChanged {
+		f(percent)
+	}
+}
+
+func clampZoom(zoom float64) float64 {
+	switch {
+	case zoom < minZoom:
+		return minZoom
+	case zoom > maxZoom:
+		return maxZoom
+	default:
+		return zoom
+	}
+}
+
+// rotate rotates the image by delta degrees, which must be a multiple of 90.
+func (v *Viewer) rotate(delta int) {
+	v.setRotation(((v.rotation+delta)%360 + 360) % 360)
+}
+
+func (v *Viewer) setRotation(degrees int) {
+	// Snap to the nearest quarter turn; rotate-90/180/270 are the only CSS
+	// classes defined.
+	degrees = ((degrees+45)/90*90%360 + 360) % 360
+
+	if v.rotation == degrees {
+		return
+	}
+
+	for _, class := range []string{"rotate-90", "rotate-180", "rotate-270"} {
+		v.Embed.RemoveCSSClass(class)
+	}
+
+	v.rotation = degrees
+
+	if degrees != 0 {
+		v.Embed.AddCSSClass(fmt.Sprintf("rotate-%d", degrees))
+	}
+}